@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+type startEgressReq struct {
+	RoomId  string `json:"room_id" validate:"required"`
+	RoomSid string `json:"room_sid" validate:"required"`
+}
+
+// HandleStartEgress starts a Room Composite egress for a running room.
+func HandleStartEgress(c *fiber.Ctx) error {
+	req := new(startEgressReq)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	m := models.NewRecorderModel()
+	info, err := m.StartEgress(req.RoomId, req.RoomSid)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"egress": info,
+	})
+}
+
+// HandleStopEgress stops a single egress by its ID.
+func HandleStopEgress(c *fiber.Ctx) error {
+	egressId := c.Params("egressId")
+	if egressId == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    "egressId is required",
+		})
+	}
+
+	m := models.NewRecorderModel()
+	if err := m.StopEgress(egressId); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+// HandleListEgress lists every egress we've tracked for a room.
+func HandleListEgress(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	if roomId == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    "roomId is required",
+		})
+	}
+
+	m := models.NewRecorderModel()
+	egresses, err := m.ListEgresses(roomId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":   true,
+		"msg":      "success",
+		"egresses": egresses,
+	})
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+type updateRoleReq struct {
+	RoomId   string `json:"room_id" validate:"required"`
+	Identity string `json:"identity" validate:"required"`
+}
+
+// HandlePromoteToSpeaker raises a listener to Speaker, e.g. after a host
+// approves their raised hand.
+func HandlePromoteToSpeaker(c *fiber.Ctx) error {
+	req := new(updateRoleReq)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	m := models.NewRoleModel()
+	if err := m.PromoteToSpeaker(req.RoomId, req.Identity); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+// HandleDemoteToListener drops a participant back to Listener.
+func HandleDemoteToListener(c *fiber.Ctx) error {
+	req := new(updateRoleReq)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	m := models.NewRoleModel()
+	if err := m.DemoteToListener(req.RoomId, req.Identity); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+// HandleCreateScheduledRoom reserves a room for a future start time; the
+// underlying LiveKit room isn't created until that time arrives.
+func HandleCreateScheduledRoom(c *fiber.Ctx) error {
+	req := new(models.ScheduledRoomReq)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	m := models.NewScheduledRoomModel()
+	room, err := m.CreateScheduledRoom(req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"room":   room,
+	})
+}
+
+// HandleListUpcomingScheduledRooms lists a host's reservations that
+// haven't started yet.
+func HandleListUpcomingScheduledRooms(c *fiber.Ctx) error {
+	hostIdentity := c.Params("hostIdentity")
+	if hostIdentity == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    "hostIdentity is required",
+		})
+	}
+
+	m := models.NewScheduledRoomModel()
+	rooms, err := m.ListUpcoming(hostIdentity)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"rooms":  rooms,
+	})
+}
+
+// HandleListPastScheduledRooms lists a host's reservations whose start
+// time has passed and whose room has not finished.
+func HandleListPastScheduledRooms(c *fiber.Ctx) error {
+	hostIdentity := c.Params("hostIdentity")
+	if hostIdentity == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status": false,
+			"msg":    "hostIdentity is required",
+		})
+	}
+
+	m := models.NewScheduledRoomModel()
+	rooms, err := m.ListPast(hostIdentity)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"rooms":  rooms,
+	})
+}
@@ -0,0 +1,93 @@
+// Package metrics exposes a Prometheus registry instrumenting webhook
+// dispatch and room/participant/track lifecycle events, so operators can
+// graph session churn and alert on stuck rooms or notifier failures
+// without grepping logs.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// roomLabelCardinalityLimit bounds how many distinct room IDs we'll ever
+// use as a label value; beyond this we fall back to "other" so a long
+// enough-lived deployment can't blow up Prometheus's label cardinality.
+const roomLabelCardinalityLimit = 2000
+
+var (
+	EventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "plugnmeet_webhook_events_total",
+		Help: "Total number of LiveKit webhook events received, by event type and room.",
+	}, []string{"event", "room"})
+
+	WebhookNotifyDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "plugnmeet_webhook_notify_duration_seconds",
+		Help:    "Time spent forwarding an event to the external webhook notifier.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"event", "room"})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "plugnmeet_handler_duration_seconds",
+		Help:    "Time spent inside a webhook event handler.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler", "room"})
+
+	ActiveRooms = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "plugnmeet_active_rooms",
+		Help: "Number of rooms currently marked as running.",
+	})
+
+	ActiveParticipants = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "plugnmeet_active_participants",
+		Help: "Number of participants currently connected across all rooms.",
+	})
+
+	ActiveTracks = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "plugnmeet_active_tracks",
+		Help: "Number of published tracks currently live, by source and room.",
+	}, []string{"source", "room"})
+)
+
+var (
+	seenRoomsMu sync.Mutex
+	seenRooms   = make(map[string]struct{}, roomLabelCardinalityLimit)
+)
+
+// BoundedRoomLabel caps the number of distinct room IDs that get used as
+// a label value across every room-scoped metric above, returning "other"
+// once the limit is reached so a long enough-lived deployment can't blow
+// up Prometheus's label cardinality.
+func BoundedRoomLabel(roomId string) string {
+	seenRoomsMu.Lock()
+	defer seenRoomsMu.Unlock()
+
+	if _, ok := seenRooms[roomId]; ok {
+		return roomId
+	}
+	if len(seenRooms) >= roomLabelCardinalityLimit {
+		return "other"
+	}
+	seenRooms[roomId] = struct{}{}
+	return roomId
+}
+
+// Serve starts a dedicated HTTP server exposing /metrics on the given
+// port. It's intentionally separate from the main API server so
+// operators can keep it off the public listener.
+func Serve(port int) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	addr := ":" + strconv.Itoa(port)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.WithError(err).Errorln("metrics server stopped")
+		}
+	}()
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import "testing"
+
+func TestBoundedRoomLabel(t *testing.T) {
+	seenRoomsMu.Lock()
+	seenRooms = make(map[string]struct{}, roomLabelCardinalityLimit)
+	seenRoomsMu.Unlock()
+
+	if got := BoundedRoomLabel("room-1"); got != "room-1" {
+		t.Errorf("first sighting of room-1 = %q, want %q", got, "room-1")
+	}
+	if got := BoundedRoomLabel("room-1"); got != "room-1" {
+		t.Errorf("repeat sighting of room-1 = %q, want %q", got, "room-1")
+	}
+
+	seenRoomsMu.Lock()
+	for i := 0; len(seenRooms) < roomLabelCardinalityLimit; i++ {
+		seenRooms[string(rune(i))] = struct{}{}
+	}
+	seenRoomsMu.Unlock()
+
+	if got := BoundedRoomLabel("brand-new-room"); got != "other" {
+		t.Errorf("sighting past the cardinality limit = %q, want %q", got, "other")
+	}
+}
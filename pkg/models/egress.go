@@ -0,0 +1,274 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	lksdk "github.com/livekit/server-sdk-go/v2"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+)
+
+const (
+	egressRedisKeyPrefix  = "pnm:egress:"
+	egressRetryMaxAttempt = 5
+	egressRetryBaseDelay  = 2 * time.Second
+)
+
+// EgressStatus mirrors the lifecycle reported by LiveKit's egress webhooks.
+type EgressStatus string
+
+const (
+	EgressStatusStarting EgressStatus = "EGRESS_STARTING"
+	EgressStatusActive   EgressStatus = "EGRESS_ACTIVE"
+	EgressStatusEnding   EgressStatus = "EGRESS_ENDING"
+	EgressStatusComplete EgressStatus = "EGRESS_COMPLETE"
+	EgressStatusFailed   EgressStatus = "EGRESS_FAILED"
+)
+
+// EgressInfo is the per-room state we keep in Redis so that any process
+// handling a later webhook or API call can pick up where another left off.
+type EgressInfo struct {
+	EgressId  string       `json:"egress_id"`
+	RoomId    string       `json:"room_id"`
+	RoomSid   string       `json:"room_sid"`
+	Status    EgressStatus `json:"status"`
+	Sink      string       `json:"sink"`
+	StartedAt int64        `json:"started_at"`
+	UpdatedAt int64        `json:"updated_at"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// EgressSink delivers a finished egress artifact to its final destination.
+// Implementations should be idempotent: Upload may be called more than
+// once for the same EgressInfo during a retry.
+type EgressSink interface {
+	Name() string
+	Upload(ctx context.Context, info *EgressInfo, filePath string) error
+}
+
+// RecorderModel drives LiveKit's egress APIs and keeps per-room egress
+// state in Redis. It replaces the old external-recorder-bot flow for
+// deployments that don't want to run a separate recorder service.
+type RecorderModel struct {
+	ctx    context.Context
+	rc     *redis.Client
+	lk     *lksdk.EgressClient
+	sinks  []EgressSink
+}
+
+func NewRecorderModel() *RecorderModel {
+	return &RecorderModel{
+		ctx: context.Background(),
+		rc:  config.AppCnf.RDS,
+		lk: lksdk.NewEgressClient(
+			config.AppCnf.LivekitInfo.Host,
+			config.AppCnf.LivekitInfo.ApiKey,
+			config.AppCnf.LivekitInfo.Secret,
+		),
+		sinks: defaultEgressSinks(),
+	}
+}
+
+func defaultEgressSinks() []EgressSink {
+	sinks := []EgressSink{&localFSSink{}}
+	if config.AppCnf.UploadFileSettings.S3.Enabled {
+		sinks = append(sinks, &s3Sink{})
+	}
+	if config.AppCnf.RecorderInfo.WebhookURL != "" {
+		sinks = append(sinks, &webhookSink{url: config.AppCnf.RecorderInfo.WebhookURL})
+	}
+	return sinks
+}
+
+// StartEgress starts a Room Composite egress for the given room and
+// records its initial state in Redis.
+func (m *RecorderModel) StartEgress(roomId, roomSid string) (*EgressInfo, error) {
+	req := &livekit.RoomCompositeEgressRequest{
+		RoomName: roomId,
+		Layout:   "speaker",
+		Output: &livekit.RoomCompositeEgressRequest_File{
+			File: &livekit.EncodedFileOutput{
+				Filepath: fmt.Sprintf("%s/%s.mp4", config.AppCnf.UploadFileSettings.Path, roomSid),
+			},
+		},
+	}
+
+	res, err := m.lk.StartRoomCompositeEgress(m.ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &EgressInfo{
+		EgressId:  res.EgressId,
+		RoomId:    roomId,
+		RoomSid:   roomSid,
+		Status:    EgressStatusStarting,
+		StartedAt: time.Now().Unix(),
+		UpdatedAt: time.Now().Unix(),
+	}
+	if err := m.saveEgressInfo(info); err != nil {
+		log.WithFields(log.Fields{"roomID": roomId, "roomSID": roomSid}).WithError(err).Errorln("failed to save egress info")
+	}
+
+	return info, nil
+}
+
+// StopEgress stops a single, running egress by its ID.
+func (m *RecorderModel) StopEgress(egressId string) error {
+	_, err := m.lk.StopEgress(m.ctx, &livekit.StopEgressRequest{
+		EgressId: egressId,
+	})
+	return err
+}
+
+// StopAllEgresses stops every egress we still have recorded for a room,
+// intended to be called as part of the roomFinished clean-up.
+func (m *RecorderModel) StopAllEgresses(roomId string) {
+	logger := log.WithField("roomID", roomId)
+
+	egresses, err := m.ListEgresses(roomId)
+	if err != nil {
+		logger.WithError(err).Errorln("failed to list egresses")
+		return
+	}
+	for _, e := range egresses {
+		if e.Status == EgressStatusComplete || e.Status == EgressStatusFailed {
+			continue
+		}
+		if err := m.StopEgress(e.EgressId); err != nil {
+			logger.WithError(err).Errorln("failed to stop egress")
+		}
+	}
+}
+
+// ListEgresses returns all egresses we've tracked for a room, most
+// recently started first.
+func (m *RecorderModel) ListEgresses(roomId string) ([]*EgressInfo, error) {
+	vals, err := m.rc.HGetAll(m.ctx, egressRedisKeyPrefix+roomId).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	egresses := make([]*EgressInfo, 0, len(vals))
+	for _, v := range vals {
+		info := new(EgressInfo)
+		if err := json.Unmarshal([]byte(v), info); err != nil {
+			continue
+		}
+		egresses = append(egresses, info)
+	}
+
+	// HGetAll has no defined order, so sort explicitly to honour the
+	// most-recently-started-first contract callers rely on.
+	sort.Slice(egresses, func(i, j int) bool {
+		return egresses[i].StartedAt > egresses[j].StartedAt
+	})
+
+	return egresses, nil
+}
+
+func (m *RecorderModel) saveEgressInfo(info *EgressInfo) error {
+	marshal, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return m.rc.HSet(m.ctx, egressRedisKeyPrefix+info.RoomId, info.EgressId, marshal).Err()
+}
+
+// loadEgressInfo returns the stored record for a single egress, or nil if
+// we don't have one (e.g. this is the first webhook we've seen for it).
+func (m *RecorderModel) loadEgressInfo(roomId, egressId string) *EgressInfo {
+	val, err := m.rc.HGet(m.ctx, egressRedisKeyPrefix+roomId, egressId).Result()
+	if err != nil {
+		return nil
+	}
+	info := new(EgressInfo)
+	if err := json.Unmarshal([]byte(val), info); err != nil {
+		return nil
+	}
+	return info
+}
+
+// HandleEgressWebhook reacts to EGRESS_STARTED/UPDATED/ENDED events coming
+// from LiveKit's webhook dispatcher and, once an egress finishes, hands
+// the resulting file off to every configured sink with retries. It
+// updates the existing record in place rather than replacing it, since
+// StartEgress's StartedAt field never comes back on these webhooks
+// and would otherwise be zeroed out on the first update.
+func (m *RecorderModel) HandleEgressWebhook(event *livekit.WebhookEvent) {
+	eg := event.GetEgressInfo()
+	if eg == nil {
+		return
+	}
+
+	info := m.loadEgressInfo(eg.RoomName, eg.EgressId)
+	if info == nil {
+		info = &EgressInfo{
+			EgressId: eg.EgressId,
+			RoomId:   eg.RoomName,
+		}
+	}
+	info.RoomSid = eg.RoomId
+	info.UpdatedAt = time.Now().Unix()
+
+	switch event.GetEvent() {
+	case "egress_started":
+		info.Status = EgressStatusStarting
+	case "egress_updated":
+		info.Status = EgressStatusActive
+	case "egress_ended":
+		if eg.Error != "" {
+			info.Status = EgressStatusFailed
+			info.Error = eg.Error
+		} else {
+			info.Status = EgressStatusComplete
+		}
+	}
+
+	if err := m.saveEgressInfo(info); err != nil {
+		log.WithFields(log.Fields{"roomID": info.RoomId, "roomSID": info.RoomSid}).WithError(err).Errorln("failed to save egress info")
+	}
+
+	if info.Status == EgressStatusComplete {
+		go m.dispatchToSinks(info, eg)
+	}
+}
+
+func (m *RecorderModel) dispatchToSinks(info *EgressInfo, eg *livekit.EgressInfo) {
+	filePath := ""
+	if f := eg.GetFile(); f != nil {
+		filePath = f.Filename
+	}
+	if filePath == "" {
+		return
+	}
+
+	logger := log.WithFields(log.Fields{"roomID": info.RoomId, "roomSID": info.RoomSid})
+
+	for _, sink := range m.sinks {
+		if err := m.uploadWithRetry(sink, info, filePath); err != nil {
+			logger.WithError(err).Errorln(fmt.Sprintf("egress sink %s failed permanently", sink.Name()))
+		}
+	}
+}
+
+func (m *RecorderModel) uploadWithRetry(sink EgressSink, info *EgressInfo, filePath string) error {
+	var err error
+	for attempt := 0; attempt < egressRetryMaxAttempt; attempt++ {
+		if attempt > 0 {
+			time.Sleep(egressRetryBaseDelay * time.Duration(1<<uint(attempt-1)))
+		}
+		if err = sink.Upload(m.ctx, info, filePath); err == nil {
+			return nil
+		}
+		log.WithFields(log.Fields{"roomID": info.RoomId, "roomSID": info.RoomSid}).WithError(err).Errorln(fmt.Sprintf("egress sink %s upload attempt %d", sink.Name(), attempt+1))
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-json"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+)
+
+// localFSSink just leaves the file where egress wrote it; it exists so
+// the local disk is treated like any other sink in dispatchToSinks.
+type localFSSink struct{}
+
+func (s *localFSSink) Name() string {
+	return "local"
+}
+
+func (s *localFSSink) Upload(_ context.Context, _ *EgressInfo, filePath string) error {
+	if _, err := os.Stat(filePath); err != nil {
+		return fmt.Errorf("local sink: %w", err)
+	}
+	return nil
+}
+
+// s3Sink uploads the finished recording to an S3-compatible bucket
+// configured via config.AppCnf.UploadFileSettings.S3.
+type s3Sink struct{}
+
+func (s *s3Sink) Name() string {
+	return "s3"
+}
+
+func (s *s3Sink) Upload(ctx context.Context, info *EgressInfo, filePath string) error {
+	client, err := config.AppCnf.UploadFileSettings.S3.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("s3 sink: %w", err)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("s3 sink: %w", err)
+	}
+	defer f.Close()
+
+	key := filepath.Join("recordings", info.RoomId, filepath.Base(filePath))
+	return client.PutObject(ctx, config.AppCnf.UploadFileSettings.S3.Bucket, key, f)
+}
+
+// webhookSink POSTs a notification with the egress metadata to an
+// operator-configured URL, letting external systems pull the file
+// themselves once it's ready.
+type webhookSink struct {
+	url string
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Upload(ctx context.Context, info *EgressInfo, filePath string) error {
+	body, err := json.Marshal(map[string]string{
+		"egress_id": info.EgressId,
+		"room_id":   info.RoomId,
+		"room_sid":  info.RoomSid,
+		"file_path": filePath,
+	})
+	if err != nil {
+		return fmt.Errorf("webhook sink: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook sink: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook sink: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook sink: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
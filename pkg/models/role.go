@@ -0,0 +1,143 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+)
+
+// Role ranks a participant along the Host > Cohost > Speaker > Listener
+// ladder used by the push-to-talk / raise-hand flow: only Speaker and
+// above may publish a microphone track.
+type Role string
+
+const (
+	RoleHost     Role = "HOST"
+	RoleCohost   Role = "COHOST"
+	RoleSpeaker  Role = "SPEAKER"
+	RoleListener Role = "LISTENER"
+)
+
+const roleRedisKeyPrefix = "pnm:role:"
+
+// CanPublishAudio reports whether a role is allowed to publish a
+// microphone track without first being promoted.
+func (r Role) CanPublishAudio() bool {
+	return r == RoleHost || r == RoleCohost || r == RoleSpeaker
+}
+
+// RoleModel tracks each participant's role for a room in Redis and
+// broadcasts changes over the existing user websocket channel so
+// clients can re-render their UI as soon as a role changes.
+type RoleModel struct {
+	ctx context.Context
+	rc  *redis.Client
+}
+
+func NewRoleModel() *RoleModel {
+	return &RoleModel{
+		ctx: context.Background(),
+		rc:  config.AppCnf.RDS,
+	}
+}
+
+// GetRole returns the participant's current role, defaulting to Listener
+// if nothing has been recorded yet (e.g. they just joined).
+func (m *RoleModel) GetRole(roomId, identity string) Role {
+	val, err := m.rc.HGet(m.ctx, roleRedisKeyPrefix+roomId, identity).Result()
+	if err != nil || val == "" {
+		return RoleListener
+	}
+	return Role(val)
+}
+
+// SetRole persists a participant's new role and broadcasts the change so
+// every connected client can update its view of the room immediately.
+func (m *RoleModel) SetRole(roomId, identity string, role Role) error {
+	if err := m.rc.HSet(m.ctx, roleRedisKeyPrefix+roomId, identity, string(role)).Err(); err != nil {
+		return err
+	}
+	return m.broadcastRoleChanged(roomId, identity, role)
+}
+
+// SeedRoleIfAbsent records a participant's initial role the first time
+// they're seen in a room, and only then: a host/admin who hasn't had a
+// role explicitly set yet would otherwise read back as RoleListener from
+// GetRole and get muted the moment they publish audio. It's a no-op for
+// a participant who has already been assigned a role, so it never
+// overwrites an explicit promotion or demotion on reconnect.
+func (m *RoleModel) SeedRoleIfAbsent(roomId, identity string, role Role) error {
+	set, err := m.rc.HSetNX(m.ctx, roleRedisKeyPrefix+roomId, identity, string(role)).Result()
+	if err != nil || !set {
+		return err
+	}
+	return m.broadcastRoleChanged(roomId, identity, role)
+}
+
+func (m *RoleModel) broadcastRoleChanged(roomId, identity string, role Role) error {
+	body, err := json.Marshal(map[string]string{
+		"identity": identity,
+		"role":     string(role),
+	})
+	if err != nil {
+		return err
+	}
+
+	msgBody := string(body)
+	msg := &WebsocketToRedis{
+		Type:   "roleChanged",
+		RoomId: roomId,
+		Msg:    &msgBody,
+	}
+	marshal, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return m.rc.Publish(m.ctx, "plug-n-meet-user-websocket", marshal).Err()
+}
+
+// PromoteToSpeaker raises a listener to Speaker, typically after a host
+// approves a raised hand.
+func (m *RoleModel) PromoteToSpeaker(roomId, identity string) error {
+	return m.SetRole(roomId, identity, RoleSpeaker)
+}
+
+// DemoteToListener drops a participant back to Listener, e.g. after a
+// host lowers their hand or ends their turn.
+func (m *RoleModel) DemoteToListener(roomId, identity string) error {
+	return m.SetRole(roomId, identity, RoleListener)
+}
+
+// EnforceMicrophonePolicy is called from the trackPublished webhook
+// handler whenever a MICROPHONE track is published. If the publisher
+// isn't at least a Speaker, the track is force-muted and unpublished and
+// a raise_hand_denied analytics event is recorded instead of letting the
+// track go live.
+func (m *RoleModel) EnforceMicrophonePolicy(roomId, identity, participantSid, trackSid string, roomService *RoomService, analyticsModel *AnalyticsModel) error {
+	role := m.GetRole(roomId, identity)
+	if role.CanPublishAudio() {
+		return nil
+	}
+
+	if err := roomService.MuteUnMuteTrack(roomId, identity, trackSid, true); err != nil {
+		return fmt.Errorf("failed to mute denied microphone track: %w", err)
+	}
+	if err := roomService.UnpublishTrack(roomId, identity, trackSid); err != nil {
+		return fmt.Errorf("failed to unpublish denied microphone track: %w", err)
+	}
+
+	analyticsModel.HandleEvent(&plugnmeet.AnalyticsDataMsg{
+		EventType: plugnmeet.AnalyticsEventType_ANALYTICS_EVENT_TYPE_USER,
+		EventName: plugnmeet.AnalyticsEvents_ANALYTICS_EVENT_RAISE_HAND_DENIED,
+		RoomId:    roomId,
+		UserId:    &identity,
+		UserSid:   &participantSid,
+	})
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+func TestRoleCanPublishAudio(t *testing.T) {
+	cases := []struct {
+		role Role
+		want bool
+	}{
+		{RoleHost, true},
+		{RoleCohost, true},
+		{RoleSpeaker, true},
+		{RoleListener, false},
+		{Role("UNKNOWN"), false},
+	}
+
+	for _, c := range cases {
+		if got := c.role.CanPublishAudio(); got != c.want {
+			t.Errorf("Role(%q).CanPublishAudio() = %v, want %v", c.role, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+)
+
+const (
+	scheduledRoomsSortedSet = "pnm:scheduledRooms"
+	scheduledRoomGraceAfter = 15 * time.Minute
+	scheduledRoomPollEvery  = 30 * time.Second
+)
+
+// ScheduledRoomReq is what a host submits to reserve a room ahead of
+// time, mirroring the reservation flow used by voice-chat apps built on
+// LiveKit: the room itself isn't created until ScheduledAt arrives.
+type ScheduledRoomReq struct {
+	RoomId       string `json:"room_id" validate:"required"`
+	HostIdentity string `json:"host_identity" validate:"required"`
+	ScheduledAt  int64  `json:"scheduled_at" validate:"required"`
+	ExpiresAt    int64  `json:"expires_at" validate:"required"`
+}
+
+// Validate checks that a reservation request is internally consistent,
+// beyond what the validate tags above cover: ScheduledAt has to be in the
+// future and before ExpiresAt, or the reservation can never actually be
+// honoured.
+func (r *ScheduledRoomReq) Validate() error {
+	if r.RoomId == "" {
+		return fmt.Errorf("room_id is required")
+	}
+	if r.HostIdentity == "" {
+		return fmt.Errorf("host_identity is required")
+	}
+	if r.ScheduledAt <= time.Now().Unix() {
+		return fmt.Errorf("scheduled_at must be in the future")
+	}
+	if r.ExpiresAt <= r.ScheduledAt {
+		return fmt.Errorf("expires_at must be after scheduled_at")
+	}
+	return nil
+}
+
+// ScheduledRoom is the persisted record of a reservation, backed by
+// MySQL for host-facing listing and a Redis sorted set (scored by
+// ScheduledAt) so the background scheduler can cheaply find whatever is
+// due next.
+type ScheduledRoom struct {
+	Id           int64  `json:"id" db:"id"`
+	RoomId       string `json:"room_id" db:"room_id"`
+	HostIdentity string `json:"host_identity" db:"host_identity"`
+	ScheduledAt  int64  `json:"scheduled_at" db:"scheduled_at"`
+	ExpiresAt    int64  `json:"expires_at" db:"expires_at"`
+	RoomCreated  bool   `json:"room_created" db:"room_created"`
+	Created      string `json:"created" db:"created"`
+}
+
+// ScheduledRoomModel owns the reservation lifecycle: creating a
+// reservation, promoting it to a real LiveKit room once it's due,
+// rejecting hosts who try to join early, and garbage-collecting created
+// rooms that are empty after they expire.
+type ScheduledRoomModel struct {
+	ctx         context.Context
+	rc          *redis.Client
+	roomModel   *RoomModel
+	roomService *RoomService
+}
+
+func NewScheduledRoomModel() *ScheduledRoomModel {
+	return &ScheduledRoomModel{
+		ctx:         context.Background(),
+		rc:          config.AppCnf.RDS,
+		roomModel:   NewRoomModel(),
+		roomService: NewRoomService(),
+	}
+}
+
+// CreateScheduledRoom persists the reservation in MySQL and indexes it in
+// the Redis sorted set so the scheduler can find it when it's due.
+func (m *ScheduledRoomModel) CreateScheduledRoom(req *ScheduledRoomReq) (*ScheduledRoom, error) {
+	room := &ScheduledRoom{
+		RoomId:       req.RoomId,
+		HostIdentity: req.HostIdentity,
+		ScheduledAt:  req.ScheduledAt,
+		ExpiresAt:    req.ExpiresAt,
+		Created:      time.Now().UTC().Format("2006-01-02 15:04:05"),
+	}
+
+	res, err := config.AppCnf.DB.ExecContext(m.ctx,
+		"INSERT INTO "+config.AppCnf.FormatDBTable("scheduled_rooms")+
+			" (room_id, host_identity, scheduled_at, expires_at, created) VALUES (?, ?, ?, ?, ?)",
+		room.RoomId, room.HostIdentity, room.ScheduledAt, room.ExpiresAt, room.Created)
+	if err != nil {
+		return nil, err
+	}
+	room.Id, _ = res.LastInsertId()
+
+	if err := m.indexInRedis(room); err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+func (m *ScheduledRoomModel) indexInRedis(room *ScheduledRoom) error {
+	marshal, err := json.Marshal(room)
+	if err != nil {
+		return err
+	}
+	return m.rc.ZAdd(m.ctx, scheduledRoomsSortedSet, redis.Z{
+		Score:  float64(room.ScheduledAt),
+		Member: marshal,
+	}).Err()
+}
+
+// RejectEarlyJoin reports whether a host is trying to join a scheduled
+// room before its ScheduledAt time, so roomStarted can refuse the
+// session instead of letting it run.
+func (m *ScheduledRoomModel) RejectEarlyJoin(roomId string) (bool, error) {
+	room, err := m.findByRoomId(roomId)
+	if err != nil {
+		return false, err
+	}
+	if room == nil {
+		// not a scheduled room, nothing to enforce
+		return false, nil
+	}
+	return time.Now().Unix() < room.ScheduledAt, nil
+}
+
+func (m *ScheduledRoomModel) findByRoomId(roomId string) (*ScheduledRoom, error) {
+	var room ScheduledRoom
+	row := config.AppCnf.DB.QueryRowContext(m.ctx,
+		"SELECT id, room_id, host_identity, scheduled_at, expires_at, room_created, created FROM "+
+			config.AppCnf.FormatDBTable("scheduled_rooms")+" WHERE room_id = ? LIMIT 1", roomId)
+
+	err := row.Scan(&room.Id, &room.RoomId, &room.HostIdentity, &room.ScheduledAt, &room.ExpiresAt, &room.RoomCreated, &room.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &room, nil
+}
+
+// CleanScheduleEntry removes a room's reservation bookkeeping once it has
+// finished, called from the roomFinished webhook handler.
+func (m *ScheduledRoomModel) CleanScheduleEntry(roomId string) {
+	_, err := config.AppCnf.DB.ExecContext(m.ctx,
+		"DELETE FROM "+config.AppCnf.FormatDBTable("scheduled_rooms")+" WHERE room_id = ?", roomId)
+	if err != nil {
+		log.WithField("roomID", roomId).WithError(err).Errorln("failed to clean scheduled room entry")
+	}
+}
+
+// ListUpcoming returns every reservation for a host that hasn't started
+// yet, soonest first.
+func (m *ScheduledRoomModel) ListUpcoming(hostIdentity string) ([]*ScheduledRoom, error) {
+	return m.listByHost(hostIdentity, "scheduled_at >= ?", time.Now().Unix(), "ASC")
+}
+
+// ListPast returns a host's reservations whose ScheduledAt has passed,
+// most recent first. CleanScheduleEntry deletes a reservation's row once
+// its room finishes, so finished reservations are not listed.
+func (m *ScheduledRoomModel) ListPast(hostIdentity string) ([]*ScheduledRoom, error) {
+	return m.listByHost(hostIdentity, "scheduled_at < ?", time.Now().Unix(), "DESC")
+}
+
+func (m *ScheduledRoomModel) listByHost(hostIdentity, cond string, cmp int64, order string) ([]*ScheduledRoom, error) {
+	rows, err := config.AppCnf.DB.QueryContext(m.ctx,
+		"SELECT id, room_id, host_identity, scheduled_at, expires_at, room_created, created FROM "+
+			config.AppCnf.FormatDBTable("scheduled_rooms")+
+			" WHERE host_identity = ? AND "+cond+" ORDER BY scheduled_at "+order,
+		hostIdentity, cmp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := make([]*ScheduledRoom, 0)
+	for rows.Next() {
+		room := new(ScheduledRoom)
+		if err := rows.Scan(&room.Id, &room.RoomId, &room.HostIdentity, &room.ScheduledAt, &room.ExpiresAt, &room.RoomCreated, &room.Created); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+
+	return rooms, nil
+}
+
+// StartScheduler runs forever, periodically creating the underlying
+// LiveKit room for every reservation that has come due and ending
+// created scheduled rooms that have no participants once their ExpiresAt
+// plus a grace period has passed.
+func (m *ScheduledRoomModel) StartScheduler() {
+	ticker := time.NewTicker(scheduledRoomPollEvery)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.createDueRooms()
+		m.cleanUpEmptyExpiredRooms()
+	}
+}
+
+func (m *ScheduledRoomModel) createDueRooms() {
+	now := float64(time.Now().Unix())
+	entries, err := m.rc.ZRangeByScore(m.ctx, scheduledRoomsSortedSet, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%f", now),
+	}).Result()
+	if err != nil {
+		log.WithError(err).Errorln("failed to read due scheduled rooms")
+		return
+	}
+
+	for _, entry := range entries {
+		room := new(ScheduledRoom)
+		if err := json.Unmarshal([]byte(entry), room); err != nil {
+			log.WithError(err).Errorln("failed to unmarshal scheduled room entry")
+			continue
+		}
+		logger := log.WithField("roomID", room.RoomId)
+
+		// Claim the entry by removing it from the sorted set before doing
+		// anything else. Multiple server instances can run this poll loop
+		// concurrently, and only the one that actually removes the member
+		// should go on to create the room; a loser sees removed == 0 and
+		// backs off, instead of both instances creating the same room.
+		removed, err := m.rc.ZRem(m.ctx, scheduledRoomsSortedSet, entry).Result()
+		if err != nil {
+			logger.WithError(err).Errorln("failed to claim due scheduled room")
+			continue
+		}
+		if removed == 0 {
+			// another instance already claimed this entry
+			continue
+		}
+
+		if _, err := m.roomService.CreateRoom(room.RoomId, nil); err != nil {
+			logger.WithError(err).Errorln("failed to create scheduled room, will retry next tick")
+			if rqErr := m.indexInRedis(room); rqErr != nil {
+				logger.WithError(rqErr).Errorln("failed to re-queue scheduled room after create failure")
+			}
+			continue
+		}
+
+		_, _ = config.AppCnf.DB.ExecContext(m.ctx,
+			"UPDATE "+config.AppCnf.FormatDBTable("scheduled_rooms")+" SET room_created = 1 WHERE room_id = ?",
+			room.RoomId)
+	}
+}
+
+func (m *ScheduledRoomModel) cleanUpEmptyExpiredRooms() {
+	rows, err := config.AppCnf.DB.QueryContext(m.ctx,
+		"SELECT room_id FROM "+config.AppCnf.FormatDBTable("scheduled_rooms")+
+			" WHERE room_created = 1 AND expires_at < ?", time.Now().Add(-scheduledRoomGraceAfter).Unix())
+	if err != nil {
+		log.WithError(err).Errorln("failed to list expired scheduled rooms")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var roomId string
+		if err := rows.Scan(&roomId); err != nil {
+			continue
+		}
+		logger := log.WithField("roomID", roomId)
+
+		participants, err := m.roomService.LoadParticipants(roomId)
+		if err != nil {
+			logger.WithError(err).Errorln("failed to check participants before cleaning up expired scheduled room")
+			continue
+		}
+		if len(participants) > 0 {
+			// someone's actually in there; the grace period only makes a
+			// room eligible, occupancy decides emptiness, so leave a filled
+			// room alone
+			continue
+		}
+
+		if _, err := m.roomService.EndRoom(roomId); err != nil {
+			logger.WithError(err).Errorln("failed to end empty expired scheduled room")
+			continue
+		}
+		m.CleanScheduleEntry(roomId)
+	}
+}
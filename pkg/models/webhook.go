@@ -7,6 +7,8 @@ import (
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
 	"github.com/mynaparrot/plugnmeet-protocol/utils"
 	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
 	"time"
@@ -16,6 +18,7 @@ type webhookEvent struct {
 	rc             *redis.Client
 	ctx            context.Context
 	event          *livekit.WebhookEvent
+	log            *log.Entry
 	roomModel      *RoomModel
 	roomService    *RoomService
 	recordingModel *RecordingModel
@@ -23,13 +26,28 @@ type webhookEvent struct {
 	userModel      *UserModel
 	notifier       *WebhookNotifierModel
 	analyticsModel *AnalyticsModel
+	roleModel      *RoleModel
 }
 
+// NewWebhookModel is the entry point LiveKit's webhook receiver calls for
+// every incoming event. It no longer processes the event inline: a
+// retried webhook could otherwise double-count participants or reinsert
+// a room, so the event is handed to the per-room queue, which dedupes it
+// and serializes it against every other event for the same room.
 func NewWebhookModel(e *livekit.WebhookEvent) {
+	NewWebhookQueueModel().Enqueue(e)
+}
+
+// processWebhookEvent performs the actual dispatch previously done by
+// NewWebhookModel. It's invoked by the per-room queue workers, so that
+// events for a single room are always handled in arrival order, and
+// inline by Enqueue for events that carry no room.
+func processWebhookEvent(e *livekit.WebhookEvent) {
 	w := &webhookEvent{
 		rc:             config.AppCnf.RDS,
 		ctx:            context.Background(),
 		event:          e,
+		log:            logEntryForEvent(e),
 		roomModel:      NewRoomModel(),
 		roomService:    NewRoomService(),
 		recordingModel: NewRecordingModel(),
@@ -37,29 +55,83 @@ func NewWebhookModel(e *livekit.WebhookEvent) {
 		userModel:      NewUserModel(),
 		notifier:       NewWebhookNotifier(),
 		analyticsModel: NewAnalyticsModel(),
+		roleModel:      NewRoleModel(),
+	}
+
+	roomLabel := ""
+	if e.Room != nil {
+		roomLabel = metrics.BoundedRoomLabel(e.Room.Name)
 	}
+	metrics.EventsTotal.WithLabelValues(e.GetEvent(), roomLabel).Inc()
 
 	switch e.GetEvent() {
 	case "room_started":
-		w.roomStarted()
+		w.timeHandler("roomStarted", roomLabel, w.roomStarted)
 	case "room_finished":
-		w.roomFinished()
+		w.timeHandler("roomFinished", roomLabel, w.roomFinished)
 
 	case "participant_joined":
-		w.participantJoined()
+		w.timeHandler("participantJoined", roomLabel, w.participantJoined)
 	case "participant_left":
-		w.participantLeft()
+		w.timeHandler("participantLeft", roomLabel, w.participantLeft)
 
 	case "track_published":
-		w.trackPublished()
+		w.timeHandler("trackPublished", roomLabel, w.trackPublished)
 	case "track_unpublished":
-		w.trackUnpublished()
+		w.timeHandler("trackUnpublished", roomLabel, w.trackUnpublished)
+
+	case "egress_started", "egress_updated", "egress_ended":
+		w.recorderModel.HandleEgressWebhook(e)
 	}
 
 }
 
+// timeHandler runs a handler while recording its duration under the
+// plugnmeet_handler_duration_seconds histogram, keyed by handler and room.
+func (w *webhookEvent) timeHandler(name, roomLabel string, handler func()) {
+	timer := prometheus.NewTimer(metrics.HandlerDuration.WithLabelValues(name, roomLabel))
+	defer timer.ObserveDuration()
+	handler()
+}
+
+// logEntryForEvent builds a *log.Entry pre-populated with the room and
+// participant identifiers carried by a webhook event, so every log line
+// emitted while handling it can be correlated back to a single session
+// without each handler re-deriving the fields by hand.
+func logEntryForEvent(e *livekit.WebhookEvent) *log.Entry {
+	fields := log.Fields{}
+
+	if room := e.GetRoom(); room != nil {
+		fields["roomID"] = room.Name
+		fields["roomSID"] = room.Sid
+	}
+	if p := e.GetParticipant(); p != nil {
+		fields["subscriber"] = p.Identity
+		fields["subscriberID"] = p.Sid
+	}
+	if t := e.GetTrack(); t != nil {
+		fields["track"] = t.Sid
+		fields["kind"] = t.Source.String()
+	}
+
+	return log.WithFields(fields)
+}
+
 func (w *webhookEvent) roomStarted() {
 	event := w.event
+	metrics.ActiveRooms.Inc()
+
+	// a room reserved for a later time shouldn't be usable early, even if
+	// LiveKit itself has already spun it up
+	scheduled := NewScheduledRoomModel()
+	if rejectEarly, err := scheduled.RejectEarlyJoin(event.Room.Name); err != nil {
+		w.log.WithError(err).Errorln("failed to check scheduled room start time")
+	} else if rejectEarly {
+		if _, err := w.roomService.EndRoom(event.Room.Name); err != nil {
+			w.log.WithError(err).Errorln("failed to end room joined before its scheduled time")
+		}
+		return
+	}
 
 	// webhook notification
 	go w.sendToWebhookNotifier(event)
@@ -73,7 +145,7 @@ func (w *webhookEvent) roomStarted() {
 	}
 	_, err := w.roomModel.InsertOrUpdateRoomData(room, false)
 	if err != nil {
-		log.Errorln(err)
+		w.log.WithError(err).Errorln("failed to insert or update room data")
 	}
 
 	if event.Room.Metadata != "" {
@@ -101,6 +173,10 @@ func (w *webhookEvent) roomStarted() {
 
 func (w *webhookEvent) roomFinished() {
 	event := w.event
+	metrics.ActiveRooms.Dec()
+
+	// clean up any reservation bookkeeping for this room
+	NewScheduledRoomModel().CleanScheduleEntry(event.Room.Name)
 
 	// webhook notification
 	go w.sendToWebhookNotifier(event)
@@ -112,15 +188,11 @@ func (w *webhookEvent) roomFinished() {
 	}
 	_, err := w.roomModel.UpdateRoomStatus(room)
 	if err != nil {
-		log.Errorln(err)
+		w.log.WithError(err).Errorln("failed to update room status")
 	}
 
-	//we'll send message to recorder to stop
-	_ = w.recorderModel.SendMsgToRecorder(&plugnmeet.RecordingReq{
-		Task:   plugnmeet.RecordingTasks_STOP,
-		Sid:    w.event.Room.Name,
-		RoomId: w.event.Room.Name,
-	})
+	// stop any egress still running for this room
+	w.recorderModel.StopAllEgresses(event.Room.Name)
 
 	// Delete all the files those may upload during session
 	if !config.AppCnf.UploadFileSettings.KeepForever {
@@ -185,6 +257,7 @@ func (w *webhookEvent) participantJoined() {
 	if event.Participant.Identity == config.RECORDER_BOT || event.Participant.Identity == config.RTMP_BOT {
 		return
 	}
+	metrics.ActiveParticipants.Inc()
 
 	// webhook notification
 	go w.sendToWebhookNotifier(event)
@@ -194,7 +267,22 @@ func (w *webhookEvent) participantJoined() {
 	}
 	_, err := w.roomModel.UpdateRoomParticipants(room, "+")
 	if err != nil {
-		log.Errorln(err)
+		w.log.WithError(err).Errorln("failed to update room participants")
+	}
+
+	// seed the participant's role from their existing admin/presenter
+	// metadata before anything can publish audio, so a host isn't
+	// defaulted to Listener (and muted) the first time they unmute
+	if info, err := w.roomService.UnmarshalParticipantMetadata(event.Participant.Metadata); err == nil {
+		role := RoleListener
+		if info.IsAdmin {
+			role = RoleHost
+		} else if info.IsPresenter {
+			role = RoleCohost
+		}
+		if err := w.roleModel.SeedRoleIfAbsent(event.Room.Name, event.Participant.Identity, role); err != nil {
+			w.log.WithError(err).Errorln("failed to seed participant role")
+		}
 	}
 
 	// send analytics
@@ -203,6 +291,7 @@ func (w *webhookEvent) participantJoined() {
 		EventName: plugnmeet.AnalyticsEvents_ANALYTICS_EVENT_USER_JOINED,
 		RoomId:    event.Room.Name,
 		UserId:    &event.Participant.Identity,
+		UserSid:   &event.Participant.Sid,
 		UserName:  &event.Participant.Name,
 		ExtraData: &event.Participant.Metadata,
 	})
@@ -214,6 +303,7 @@ func (w *webhookEvent) participantLeft() {
 	if event.Participant.Identity == config.RECORDER_BOT || event.Participant.Identity == config.RTMP_BOT {
 		return
 	}
+	metrics.ActiveParticipants.Dec()
 
 	// webhook notification
 	go w.sendToWebhookNotifier(event)
@@ -223,7 +313,7 @@ func (w *webhookEvent) participantLeft() {
 	}
 	_, err := w.roomModel.UpdateRoomParticipants(room, "-")
 	if err != nil {
-		log.Errorln(err)
+		w.log.WithError(err).Errorln("failed to update room participants")
 	}
 
 	// if we missed to calculate this user's speech service usage stat
@@ -237,19 +327,31 @@ func (w *webhookEvent) participantLeft() {
 		EventName: plugnmeet.AnalyticsEvents_ANALYTICS_EVENT_USER_LEFT,
 		RoomId:    event.Room.Name,
 		UserId:    &event.Participant.Identity,
+		UserSid:   &event.Participant.Sid,
 	})
 }
 
 func (w *webhookEvent) trackPublished() {
+	metrics.ActiveTracks.WithLabelValues(w.event.Track.Source.String(), metrics.BoundedRoomLabel(w.event.Room.Name)).Inc()
+
 	// webhook notification
 	go w.sendToWebhookNotifier(w.event)
 
+	// a listener may only bring a microphone live by first being promoted
+	// to Speaker; anything else gets force-muted and unpublished
+	if w.event.Track.Source == livekit.TrackSource_MICROPHONE {
+		if err := w.roleModel.EnforceMicrophonePolicy(w.event.Room.Name, w.event.Participant.Identity, w.event.Participant.Sid, w.event.Track.Sid, w.roomService, w.analyticsModel); err != nil {
+			w.log.WithError(err).Errorln("failed to enforce microphone role policy")
+		}
+	}
+
 	// send analytics
 	var val string
 	data := &plugnmeet.AnalyticsDataMsg{
 		EventType: plugnmeet.AnalyticsEventType_ANALYTICS_EVENT_TYPE_USER,
 		RoomId:    w.event.Room.Name,
 		UserId:    &w.event.Participant.Identity,
+		UserSid:   &w.event.Participant.Sid,
 	}
 
 	switch w.event.Track.Source {
@@ -269,6 +371,8 @@ func (w *webhookEvent) trackPublished() {
 }
 
 func (w *webhookEvent) trackUnpublished() {
+	metrics.ActiveTracks.WithLabelValues(w.event.Track.Source.String(), metrics.BoundedRoomLabel(w.event.Room.Name)).Dec()
+
 	// webhook notification
 	go w.sendToWebhookNotifier(w.event)
 
@@ -278,6 +382,7 @@ func (w *webhookEvent) trackUnpublished() {
 		EventType: plugnmeet.AnalyticsEventType_ANALYTICS_EVENT_TYPE_USER,
 		RoomId:    w.event.Room.Name,
 		UserId:    &w.event.Participant.Identity,
+		UserSid:   &w.event.Participant.Sid,
 	}
 
 	switch w.event.Track.Source {
@@ -298,13 +403,16 @@ func (w *webhookEvent) trackUnpublished() {
 
 func (w *webhookEvent) sendToWebhookNotifier(event *livekit.WebhookEvent) {
 	if event.Room == nil {
-		log.Errorln("empty room info for event: ", event.GetEvent())
+		w.log.Errorln("empty room info for event: ", event.GetEvent())
 		return
 	}
 
+	timer := prometheus.NewTimer(metrics.WebhookNotifyDuration.WithLabelValues(event.GetEvent(), metrics.BoundedRoomLabel(event.Room.Name)))
+	defer timer.ObserveDuration()
+
 	msg := utils.PrepareCommonWebhookNotifyEvent(event)
 	err := w.notifier.Notify(event.Room.Sid, msg)
 	if err != nil {
-		log.Errorln(err)
+		w.log.WithError(err).Errorln("failed to notify webhook")
 	}
 }
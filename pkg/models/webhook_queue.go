@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+)
+
+const (
+	webhookDedupKeyPrefix  = "pnm:webhook:dedup:"
+	webhookDedupTTL        = 24 * time.Hour
+	webhookQueueKeyPrefix  = "pnm:webhook:queue:"
+	webhookQueuePendingSet = "pnm:webhook:queue:rooms"
+	webhookQueuePopTimeout = 5 * time.Second
+)
+
+// webhookRunningWorkers tracks which rooms already have a drain goroutine
+// running so Enqueue doesn't spin up a second worker for the same room.
+// webhookRunningWorkersMu guards every read/write of the map together with
+// the Redis length check that decides whether a worker is allowed to give
+// up its slot, so that decision and the map update happen as one atomic
+// step: see the comment on drain below for why that matters.
+var (
+	webhookRunningWorkersMu sync.Mutex
+	webhookRunningWorkers   = make(map[string]bool)
+)
+
+// WebhookQueueModel gives every LiveKit webhook event a dedup check and a
+// place in a per-room FIFO queue, so a webhook retry can't double-count a
+// participant or reinsert a room, and so events for the same room are
+// always handled in the order they arrived, even across process restarts.
+type WebhookQueueModel struct {
+	ctx context.Context
+	rc  *redis.Client
+}
+
+func NewWebhookQueueModel() *WebhookQueueModel {
+	return &WebhookQueueModel{
+		ctx: context.Background(),
+		rc:  config.AppCnf.RDS,
+	}
+}
+
+// Enqueue dedupes an event by its ID and, if it's new, pushes it onto its
+// room's queue and makes sure a worker is draining that queue.
+func (m *WebhookQueueModel) Enqueue(e *livekit.WebhookEvent) {
+	roomId := ""
+	if e.Room != nil {
+		roomId = e.Room.Name
+	}
+	logger := log.WithField("roomID", roomId)
+
+	isNew, err := m.markSeen(e.Id)
+	if err != nil {
+		logger.WithError(err).Errorln("webhook dedup check failed")
+		// fail open: better to risk a duplicate than drop the event
+	} else if !isNew {
+		return
+	}
+
+	if roomId == "" {
+		// events without a room (e.g. egress events, which carry their
+		// room in EgressInfo instead) can't be ordered against anything,
+		// so just run them inline
+		processWebhookEvent(e)
+		return
+	}
+
+	if err := m.push(roomId, e); err != nil {
+		logger.WithError(err).Errorln("failed to enqueue webhook event")
+		return
+	}
+
+	m.ensureWorker(roomId)
+}
+
+func (m *WebhookQueueModel) markSeen(eventId string) (bool, error) {
+	if eventId == "" {
+		// events missing an ID can't be deduped; treat every one as new
+		return true, nil
+	}
+	return m.rc.SetNX(m.ctx, webhookDedupKeyPrefix+eventId, 1, webhookDedupTTL).Result()
+}
+
+func (m *WebhookQueueModel) push(roomId string, e *livekit.WebhookEvent) error {
+	marshal, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := m.rc.SAdd(m.ctx, webhookQueuePendingSet, roomId).Err(); err != nil {
+		return err
+	}
+	return m.rc.RPush(m.ctx, webhookQueueKeyPrefix+roomId, marshal).Err()
+}
+
+func (m *WebhookQueueModel) ensureWorker(roomId string) {
+	webhookRunningWorkersMu.Lock()
+	alreadyRunning := webhookRunningWorkers[roomId]
+	if !alreadyRunning {
+		webhookRunningWorkers[roomId] = true
+	}
+	webhookRunningWorkersMu.Unlock()
+
+	if alreadyRunning {
+		return
+	}
+	go m.drain(roomId)
+}
+
+// drain pops events for a single room one at a time, in FIFO order,
+// until the queue has been empty long enough that we give up the worker
+// slot; the next Enqueue for this room will spin up a fresh one.
+//
+// Giving up the slot races against a concurrent Enqueue: BLPop can time
+// out right as a new event is pushed, after Enqueue already saw this
+// worker as "running" and decided not to start another one. A plain
+// re-check of the queue length narrows that window but doesn't close it,
+// since the length check and the map delete are still two separate
+// steps. So both this exit path and ensureWorker's claim above take
+// webhookRunningWorkersMu for the whole decision: because Enqueue always
+// pushes the event before calling ensureWorker, whichever side gets the
+// lock first sees a queue/map state that already reflects the other
+// side's most recent action, and the worker that should keep running
+// always does.
+func (m *WebhookQueueModel) drain(roomId string) {
+	logger := log.WithField("roomID", roomId)
+	key := webhookQueueKeyPrefix + roomId
+
+	for {
+		res, err := m.rc.BLPop(m.ctx, webhookQueuePopTimeout, key).Result()
+		if err == redis.Nil {
+			webhookRunningWorkersMu.Lock()
+			length, lerr := m.rc.LLen(m.ctx, key).Result()
+			if lerr == nil && length > 0 {
+				// an Enqueue slipped an event in just as BLPop timed out;
+				// keep this worker alive for it instead of exiting
+				webhookRunningWorkersMu.Unlock()
+				continue
+			}
+			delete(webhookRunningWorkers, roomId)
+			webhookRunningWorkersMu.Unlock()
+			_ = m.rc.SRem(m.ctx, webhookQueuePendingSet, roomId).Err()
+			return
+		}
+		if err != nil {
+			logger.WithError(err).Errorln("webhook queue pop failed")
+			webhookRunningWorkersMu.Lock()
+			delete(webhookRunningWorkers, roomId)
+			webhookRunningWorkersMu.Unlock()
+			return
+		}
+
+		// res[0] is the key name, res[1] is the popped value
+		e := new(livekit.WebhookEvent)
+		if err := json.Unmarshal([]byte(res[1]), e); err != nil {
+			logger.WithError(err).Errorln("failed to unmarshal queued webhook event")
+			continue
+		}
+		processWebhookEvent(e)
+	}
+}
+
+// RecoverPendingQueues is called once at startup to resume draining any
+// room queues that still had events in them when the process last
+// stopped, so an unclean restart doesn't silently drop work.
+func RecoverPendingQueues() {
+	m := NewWebhookQueueModel()
+	rooms, err := m.rc.SMembers(m.ctx, webhookQueuePendingSet).Result()
+	if err != nil {
+		log.WithError(err).Errorln("failed to list pending webhook queues")
+		return
+	}
+	for _, roomId := range rooms {
+		m.ensureWorker(roomId)
+	}
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestWebhookQueueModel(t *testing.T) *WebhookQueueModel {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rc.Close() })
+	return &WebhookQueueModel{ctx: context.Background(), rc: rc}
+}
+
+func TestMarkSeenDedupesByEventId(t *testing.T) {
+	m := newTestWebhookQueueModel(t)
+
+	isNew, err := m.markSeen("evt-1")
+	if err != nil {
+		t.Fatalf("markSeen: %v", err)
+	}
+	if !isNew {
+		t.Fatal("first sighting of evt-1 should be new")
+	}
+
+	isNew, err = m.markSeen("evt-1")
+	if err != nil {
+		t.Fatalf("markSeen: %v", err)
+	}
+	if isNew {
+		t.Fatal("repeat sighting of evt-1 should not be new")
+	}
+}
+
+func TestMarkSeenTreatsMissingIdAsAlwaysNew(t *testing.T) {
+	m := newTestWebhookQueueModel(t)
+
+	for i := 0; i < 3; i++ {
+		isNew, err := m.markSeen("")
+		if err != nil {
+			t.Fatalf("markSeen: %v", err)
+		}
+		if !isNew {
+			t.Fatal("events without an ID should never be deduped")
+		}
+	}
+}
+
+func TestPushPreservesFIFOOrderAndTracksPendingRoom(t *testing.T) {
+	m := newTestWebhookQueueModel(t)
+
+	for _, id := range []string{"evt-1", "evt-2", "evt-3"} {
+		e := &livekit.WebhookEvent{Id: id, Event: "room_started", Room: &livekit.Room{Name: "room-1"}}
+		if err := m.push("room-1", e); err != nil {
+			t.Fatalf("push(%s): %v", id, err)
+		}
+	}
+
+	vals, err := m.rc.LRange(m.ctx, webhookQueueKeyPrefix+"room-1", 0, -1).Result()
+	if err != nil {
+		t.Fatalf("LRange: %v", err)
+	}
+	if len(vals) != 3 {
+		t.Fatalf("got %d queued events, want 3", len(vals))
+	}
+
+	for i, want := range []string{"evt-1", "evt-2", "evt-3"} {
+		e := new(livekit.WebhookEvent)
+		if err := json.Unmarshal([]byte(vals[i]), e); err != nil {
+			t.Fatalf("unmarshal entry %d: %v", i, err)
+		}
+		if e.Id != want {
+			t.Errorf("entry %d = %q, want %q", i, e.Id, want)
+		}
+	}
+
+	isMember, err := m.rc.SIsMember(m.ctx, webhookQueuePendingSet, "room-1").Result()
+	if err != nil {
+		t.Fatalf("SIsMember: %v", err)
+	}
+	if !isMember {
+		t.Fatal("room-1 should be tracked in the pending set after a push")
+	}
+}
+
+func TestEnsureWorkerDoesNotDoubleClaimARunningRoom(t *testing.T) {
+	webhookRunningWorkersMu.Lock()
+	webhookRunningWorkers = make(map[string]bool)
+	webhookRunningWorkersMu.Unlock()
+
+	webhookRunningWorkersMu.Lock()
+	webhookRunningWorkers["room-1"] = true
+	webhookRunningWorkersMu.Unlock()
+
+	m := newTestWebhookQueueModel(t)
+	// With a worker already marked as running, ensureWorker must not spawn
+	// a second one; if it did, this call would start draining an empty
+	// queue instead of returning immediately, which shows up as one more
+	// goroutine.
+	before := runtime.NumGoroutine()
+	m.ensureWorker("room-1")
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("ensureWorker started a second worker for room-1 (%d -> %d goroutines)", before, after)
+	}
+
+	webhookRunningWorkersMu.Lock()
+	defer webhookRunningWorkersMu.Unlock()
+	if !webhookRunningWorkers["room-1"] {
+		t.Fatal("room-1 should still be marked as running")
+	}
+}
@@ -0,0 +1,62 @@
+// Package routers wires the HTTP API surface together. main calls New()
+// once at startup to get the fiber.App it listens with.
+package routers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/controllers"
+	"github.com/mynaparrot/plugnmeet-server/pkg/metrics"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+// defaultMetricsPort is where Prometheus scrapes /metrics from when
+// config.AppCnf.MetricsInfo.Port isn't set. It's deliberately a separate
+// listener from the API server below so operators can keep it off the
+// public-facing port.
+const defaultMetricsPort = 9100
+
+func metricsPort() int {
+	if p := config.AppCnf.MetricsInfo.Port; p != 0 {
+		return p
+	}
+	return defaultMetricsPort
+}
+
+// New builds the fiber.App with every route group mounted and starts the
+// metrics server and background workers alongside it.
+func New() *fiber.App {
+	metrics.Serve(metricsPort())
+	models.RecoverPendingQueues()
+	go models.NewScheduledRoomModel().StartScheduler()
+
+	app := fiber.New()
+
+	api := app.Group("/api")
+	registerEgressRoutes(api)
+	registerRoleRoutes(api)
+	registerScheduledRoomRoutes(api)
+
+	return app
+}
+
+func registerEgressRoutes(router fiber.Router) {
+	egress := router.Group("/egress")
+	egress.Post("/start", controllers.HandleStartEgress)
+	egress.Post("/stop/:egressId", controllers.HandleStopEgress)
+	egress.Get("/list/:roomId", controllers.HandleListEgress)
+}
+
+func registerRoleRoutes(router fiber.Router) {
+	role := router.Group("/role")
+	role.Post("/promote", controllers.HandlePromoteToSpeaker)
+	role.Post("/demote", controllers.HandleDemoteToListener)
+}
+
+func registerScheduledRoomRoutes(router fiber.Router) {
+	scheduled := router.Group("/scheduledRoom")
+	scheduled.Post("/create", controllers.HandleCreateScheduledRoom)
+	scheduled.Get("/upcoming/:hostIdentity", controllers.HandleListUpcomingScheduledRooms)
+	scheduled.Get("/past/:hostIdentity", controllers.HandleListPastScheduledRooms)
+}